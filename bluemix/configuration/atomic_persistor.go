@@ -0,0 +1,69 @@
+package configuration
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// atomicDiskPersistor is a disk-backed Persistor that never leaves the
+// config file half-written: Save serializes to a temp file in the same
+// directory and renames it into place, which is atomic on every OS this
+// CLI supports. A crash or concurrent CLI invocation can therefore only
+// ever observe the old file or the new one, never a truncated one.
+type atomicDiskPersistor struct {
+	path string
+}
+
+// NewAtomicDiskPersistor returns a Persistor backed by the file at path,
+// writing crash-safely via a temp file + rename.
+func NewAtomicDiskPersistor(path string) Persistor {
+	return &atomicDiskPersistor{path: path}
+}
+
+func (p *atomicDiskPersistor) Load(data Data) error {
+	bytes, err := ioutil.ReadFile(p.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return data.Unmarshal(bytes)
+}
+
+func (p *atomicDiskPersistor) Save(data Data) error {
+	bytes, err := data.Marshal()
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(p.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, filepath.Base(p.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(bytes); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, p.path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
@@ -1,15 +1,26 @@
 package core_config
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/IBM-Bluemix/bluemix-cli-sdk/bluemix/configuration"
+	"github.com/IBM-Bluemix/bluemix-cli-sdk/bluemix/configuration/config_helpers"
 	"github.com/IBM-Bluemix/bluemix-cli-sdk/bluemix/models"
 	"github.com/fatih/structs"
+	"golang.org/x/sync/singleflight"
 )
 
+// currentSchemaVersion is the BXConfigData.SchemaVersion this version of
+// the SDK understands. Bump it and append a migration func to
+// configMigrations whenever a field is added, renamed, or restructured
+// in a way older CLIs sharing the same config file wouldn't understand.
+const currentSchemaVersion = 1
+
 type raw map[string]interface{}
 
 func (r raw) Marshal() ([]byte, error) {
@@ -21,6 +32,7 @@ func (r raw) Unmarshal(bytes []byte) error {
 }
 
 type BXConfigData struct {
+	SchemaVersion           int
 	ConsoleEndpoint         string
 	Region                  string
 	RegionID                string
@@ -69,35 +81,166 @@ func (data *BXConfigData) Unmarshal(bytes []byte) error {
 }
 
 type bxConfigRepository struct {
-	data      *BXConfigData
-	persistor configuration.Persistor
-	initOnce  *sync.Once
-	lock      sync.RWMutex
-	onError   func(error)
+	data        *BXConfigData
+	persistor   configuration.Persistor
+	secretStore SecretStore
+	initOnce    *sync.Once
+	lock        sync.RWMutex
+	onError     func(error)
+
+	tokenRefresher   TokenRefresher
+	tokenRefreshSkew time.Duration
+	refreshGroup     singleflight.Group
 }
 
 func createBluemixConfigFromPath(configPath string, errHandler func(error)) *bxConfigRepository {
-	return createBluemixConfigFromPersistor(configuration.NewDiskPersistor(configPath), errHandler)
+	return createBluemixConfigFromPersistor(configuration.NewAtomicDiskPersistor(configPath), errHandler)
 }
 
 func createBluemixConfigFromPersistor(persistor configuration.Persistor, errHandler func(error)) *bxConfigRepository {
+	return createBluemixConfigFromPersistorWithSecretStore(persistor, noopSecretStore{}, errHandler)
+}
+
+func createBluemixConfigFromPersistorWithSecretStore(persistor configuration.Persistor, secretStore SecretStore, errHandler func(error)) *bxConfigRepository {
 	return &bxConfigRepository{
-		data:      NewBXConfigData(),
-		persistor: persistor,
-		initOnce:  new(sync.Once),
-		onError:   errHandler,
+		data:        NewBXConfigData(),
+		persistor:   persistor,
+		secretStore: secretStore,
+		initOnce:    new(sync.Once),
+		onError:     errHandler,
 	}
 }
 
+// NewCoreConfigWithSecretStore behaves like NewCoreConfig but persists
+// IAMToken/IAMRefreshToken through secretStore instead of the plain-text
+// config file. Pass a nil secretStore to opt back out and store tokens
+// inline, e.g. for tests.
+func NewCoreConfigWithSecretStore(errHandler func(error), secretStore SecretStore) *bxConfigRepository {
+	if secretStore == nil {
+		secretStore = noopSecretStore{}
+	}
+	return createBluemixConfigFromPersistorWithSecretStore(
+		configuration.NewAtomicDiskPersistor(config_helpers.ConfigFilePath()),
+		secretStore,
+		errHandler,
+	)
+}
+
+// noopSecretStore keeps tokens inline in the config file, preserving the
+// pre-SecretStore behavior.
+type noopSecretStore struct{}
+
+func (noopSecretStore) Get(key string) (string, bool) { return "", false }
+func (noopSecretStore) Set(key string, value string) error { return nil }
+func (noopSecretStore) Delete(key string) error { return nil }
+
 func (c *bxConfigRepository) init() {
 	c.initOnce.Do(func() {
 		err := c.persistor.Load(c.data)
 		if err != nil {
 			c.onError(err)
+			return
+		}
+
+		if err := c.migrate(); err != nil {
+			c.onError(err)
 		}
 	})
 }
 
+// migrate brings a config file loaded from disk up to currentSchemaVersion,
+// running configMigrations in order and writing the result back so the
+// migration only has to happen once. Configs newer than this SDK
+// understands are reported via ErrConfigTooNew rather than risking data
+// loss by writing over fields we don't recognize.
+func (c *bxConfigRepository) migrate() error {
+	if len(c.data.raw) == 0 {
+		// Nothing on disk yet; stamp currentSchemaVersion for whenever
+		// this gets saved. A file that exists but has no SchemaVersion
+		// key keeps the zero value and runs every migration.
+		c.data.SchemaVersion = currentSchemaVersion
+		return nil
+	}
+
+	if c.data.SchemaVersion > currentSchemaVersion {
+		return &ErrConfigTooNew{FoundVersion: c.data.SchemaVersion, SupportedVersion: currentSchemaVersion}
+	}
+	if c.data.SchemaVersion < 0 {
+		return fmt.Errorf("config file schema version %d is invalid", c.data.SchemaVersion)
+	}
+
+	migrated := c.data.raw
+	changed := false
+	for version := c.data.SchemaVersion; version < currentSchemaVersion; version++ {
+		var err error
+		migrated, err = configMigrations[version](migrated)
+		if err != nil {
+			return fmt.Errorf("migrating config from schema version %d: %v", version, err)
+		}
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	bytes, err := migrated.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := c.data.Unmarshal(bytes); err != nil {
+		return err
+	}
+
+	c.data.SchemaVersion = currentSchemaVersion
+	c.data.raw["SchemaVersion"] = currentSchemaVersion
+
+	return c.persistor.Save(c.data)
+}
+
+// ErrConfigTooNew is returned when a config file's SchemaVersion is newer
+// than this SDK understands, e.g. after downgrading the CLI. Callers
+// should surface this to the user rather than silently ignoring or
+// overwriting fields they can't interpret.
+type ErrConfigTooNew struct {
+	FoundVersion     int
+	SupportedVersion int
+}
+
+func (e *ErrConfigTooNew) Error() string {
+	return fmt.Sprintf("config file schema version %d is newer than the %d supported by this CLI; please upgrade", e.FoundVersion, e.SupportedVersion)
+}
+
+// configMigrations holds one migration per schema version bump;
+// configMigrations[i] upgrades a raw config from version i to i+1.
+var configMigrations = []func(raw) (raw, error){
+	migrateLegacyFieldNames,
+}
+
+// legacyFieldRenames maps field names used by older CLIs to the names
+// BXConfigData uses today. migrateLegacyFieldNames is schema version 0's
+// migration, folding those old configs into version 1.
+var legacyFieldRenames = map[string]string{
+	"IamToken":        "IAMToken",
+	"IamRefreshToken": "IAMRefreshToken",
+	"IamEndpoint":     "IAMEndpoint",
+	"IamID":           "IAMID",
+}
+
+func migrateLegacyFieldNames(r raw) (raw, error) {
+	for oldKey, newKey := range legacyFieldRenames {
+		value, ok := r[oldKey]
+		if !ok {
+			continue
+		}
+		if _, exists := r[newKey]; !exists {
+			r[newKey] = value
+		}
+		delete(r, oldKey)
+	}
+	return r, nil
+}
+
 func (c *bxConfigRepository) read(cb func()) {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
@@ -199,18 +342,51 @@ func (c *bxConfigRepository) IAMID() string {
 
 func (c *bxConfigRepository) IAMToken() (token string) {
 	c.read(func() {
-		token = c.data.IAMToken
+		token = c.resolveSecret(c.data.IAMToken)
 	})
+
+	c.lock.RLock()
+	refresher := c.tokenRefresher
+	c.lock.RUnlock()
+
+	if refresher == nil {
+		return
+	}
+
+	exp, ok := jwtExpiry(token)
+	if ok && time.Now().Add(c.refreshSkew()).Before(exp) {
+		return
+	}
+
+	if refreshed, err := c.RefreshIAMToken(context.Background()); err == nil && refreshed != "" {
+		token = refreshed
+	}
 	return
 }
 
 func (c *bxConfigRepository) IAMRefreshToken() (token string) {
 	c.read(func() {
-		token = c.data.IAMRefreshToken
+		token = c.resolveSecret(c.data.IAMRefreshToken)
 	})
 	return
 }
 
+// resolveSecret dereferences value if it is a handle into c.secretStore,
+// otherwise returns it unchanged. This lets older config files with the
+// token stored inline keep working after upgrading to a secret store.
+func (c *bxConfigRepository) resolveSecret(value string) string {
+	key, ok := isSecretHandle(value)
+	if !ok {
+		return value
+	}
+
+	secret, found := c.secretStore.Get(key)
+	if !found {
+		return ""
+	}
+	return secret
+}
+
 func (c *bxConfigRepository) Account() (account models.Account) {
 	c.read(func() {
 		account = c.data.Account
@@ -328,18 +504,36 @@ func (c *bxConfigRepository) SetIAMEndpoint(endpoint string) {
 
 func (c *bxConfigRepository) SetIAMToken(token string) {
 	c.writeRaw(func() {
-		c.data.IAMToken = token
-		c.data.raw["IAMToken"] = token
+		value := c.storeSecret("iam-token", token)
+		c.data.IAMToken = value
+		c.data.raw["IAMToken"] = value
 	})
 }
 
 func (c *bxConfigRepository) SetIAMRefreshToken(token string) {
 	c.writeRaw(func() {
-		c.data.IAMRefreshToken = token
-		c.data.raw["IAMRefreshToken"] = token
+		value := c.storeSecret("iam-refresh-token", token)
+		c.data.IAMRefreshToken = value
+		c.data.raw["IAMRefreshToken"] = value
 	})
 }
 
+// storeSecret writes token into c.secretStore under key and returns the
+// handle that should be persisted to the config file in its place. If the
+// store write fails, the token is kept inline so callers don't silently
+// lose credentials. A noopSecretStore is a pure passthrough: token is kept
+// inline without ever touching Set, since Set always reports success.
+func (c *bxConfigRepository) storeSecret(key string, token string) string {
+	if _, ok := c.secretStore.(noopSecretStore); ok {
+		return token
+	}
+	if err := c.secretStore.Set(key, token); err != nil {
+		c.onError(err)
+		return token
+	}
+	return handleFor(key)
+}
+
 func (c *bxConfigRepository) SetAccount(account models.Account) {
 	c.write(func() {
 		c.data.Account = account
@@ -416,6 +610,8 @@ func (c *bxConfigRepository) SetTrace(trace string) {
 
 func (c *bxConfigRepository) ClearSession() {
 	c.write(func() {
+		c.secretStore.Delete("iam-token")
+		c.secretStore.Delete("iam-refresh-token")
 		c.data.IAMToken = ""
 		c.data.IAMRefreshToken = ""
 		c.data.Account = models.Account{}
@@ -0,0 +1,65 @@
+package core_config
+
+import "testing"
+
+func TestMigrateRejectsNegativeSchemaVersion(t *testing.T) {
+	c := &bxConfigRepository{
+		data: &BXConfigData{SchemaVersion: -1, raw: raw{"Region": "us-south"}},
+	}
+
+	err := c.migrate()
+	if err == nil {
+		t.Fatal("migrate() with a negative SchemaVersion should return an error, got nil")
+	}
+}
+
+func TestMigrateLegacyFieldNames(t *testing.T) {
+	cases := []struct {
+		name  string
+		input raw
+		want  raw
+	}{
+		{
+			name:  "renames legacy Iam* fields",
+			input: raw{"IamToken": "tok", "IamRefreshToken": "refresh", "IamEndpoint": "ep", "IamID": "id"},
+			want:  raw{"IAMToken": "tok", "IAMRefreshToken": "refresh", "IAMEndpoint": "ep", "IAMID": "id"},
+		},
+		{
+			name:  "leaves already-renamed fields alone",
+			input: raw{"IAMToken": "tok"},
+			want:  raw{"IAMToken": "tok"},
+		},
+		{
+			name:  "does not overwrite a new-style field that already exists",
+			input: raw{"IamToken": "old", "IAMToken": "new"},
+			want:  raw{"IAMToken": "new"},
+		},
+		{
+			name:  "unrelated fields pass through untouched",
+			input: raw{"Region": "us-south"},
+			want:  raw{"Region": "us-south"},
+		},
+		{
+			name:  "empty input",
+			input: raw{},
+			want:  raw{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := migrateLegacyFieldNames(tc.input)
+			if err != nil {
+				t.Fatalf("migrateLegacyFieldNames() error = %v", err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("migrateLegacyFieldNames() = %v, want %v", got, tc.want)
+			}
+			for k, v := range tc.want {
+				if got[k] != v {
+					t.Errorf("migrateLegacyFieldNames()[%q] = %v, want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+}
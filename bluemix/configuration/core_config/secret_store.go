@@ -0,0 +1,35 @@
+package core_config
+
+import "strings"
+
+// secretHandlePrefix marks a config value as a reference into a SecretStore
+// rather than the secret material itself, e.g. "keyring:iam-token".
+const secretHandlePrefix = "keyring:"
+
+// SecretStore abstracts a backend capable of storing short opaque secrets
+// (bearer tokens, refresh tokens) outside of the plain-text config file.
+// Implementations include OS-native credential stores and an
+// passphrase-encrypted file fallback.
+type SecretStore interface {
+	// Get returns the secret for key, or ("", false) if it isn't set.
+	Get(key string) (string, bool)
+	// Set stores value under key, overwriting any existing value.
+	Set(key string, value string) error
+	// Delete removes key. It is not an error if key doesn't exist.
+	Delete(key string) error
+}
+
+// handleFor returns the on-disk handle used to reference a secret stored
+// under key in the secret store.
+func handleFor(key string) string {
+	return secretHandlePrefix + key
+}
+
+// isSecretHandle reports whether value is a reference into a SecretStore
+// rather than a literal secret, and returns the key it references.
+func isSecretHandle(value string) (key string, ok bool) {
+	if !strings.HasPrefix(value, secretHandlePrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(value, secretHandlePrefix), true
+}
@@ -0,0 +1,50 @@
+// +build darwin
+
+package core_config
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+)
+
+const keychainService = "bluemix-cli"
+
+// keychainSecretStore stores secrets in the macOS login Keychain via the
+// `security` command line tool, avoiding a cgo dependency on Security.framework.
+type keychainSecretStore struct{}
+
+// NewSecretStore returns the preferred SecretStore for the current platform.
+func NewSecretStore() SecretStore {
+	return &keychainSecretStore{}
+}
+
+func (s *keychainSecretStore) Get(key string) (string, bool) {
+	out, err := exec.Command("security", "find-generic-password", "-a", key, "-s", keychainService, "-w").Output()
+	if err != nil {
+		return "", false
+	}
+	return string(bytes.TrimSpace(out)), true
+}
+
+func (s *keychainSecretStore) Set(key string, value string) error {
+	// -U updates the item in place if it already exists.
+	cmd := exec.Command("security", "add-generic-password", "-a", key, "-s", keychainService, "-w", value, "-U")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("keychain: %v: %s", err, out)
+	}
+	return nil
+}
+
+func (s *keychainSecretStore) Delete(key string) error {
+	cmd := exec.Command("security", "delete-generic-password", "-a", key, "-s", keychainService)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		// security exits with 44 (errSecItemNotFound) when there is
+		// nothing to delete.
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 44 {
+			return nil
+		}
+		return fmt.Errorf("keychain: %v: %s", err, out)
+	}
+	return nil
+}
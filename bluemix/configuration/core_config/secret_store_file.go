@@ -0,0 +1,182 @@
+package core_config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	fileSecretStoreSaltSize = 16
+	fileSecretStoreKeySize  = 32
+	fileSecretStoreScryptN  = 1 << 15
+	fileSecretStoreScryptR  = 8
+	fileSecretStoreScryptP  = 1
+)
+
+// fileSecretStore is the passphrase-encrypted file fallback used when no
+// OS credential store is available. Secrets are encrypted with AES-GCM
+// using a key derived from the passphrase via scrypt, and the whole file
+// is rewritten on every Set/Delete.
+type fileSecretStore struct {
+	path       string
+	passphrase []byte
+}
+
+type fileSecretStoreDocument struct {
+	Salt    []byte            `json:"salt"`
+	Secrets map[string][]byte `json:"secrets"`
+}
+
+// NewFileSecretStore returns a SecretStore that keeps its secrets encrypted
+// at rest in a single file under path, protected by passphrase.
+func NewFileSecretStore(path string, passphrase string) SecretStore {
+	return &fileSecretStore{
+		path:       path,
+		passphrase: []byte(passphrase),
+	}
+}
+
+func (s *fileSecretStore) Get(key string) (string, bool) {
+	doc, err := s.load()
+	if err != nil {
+		return "", false
+	}
+
+	plain, ok := doc.Secrets[key]
+	if !ok {
+		return "", false
+	}
+
+	value, err := s.decrypt(doc.Salt, plain)
+	if err != nil {
+		return "", false
+	}
+	return string(value), true
+}
+
+func (s *fileSecretStore) Set(key string, value string) error {
+	doc, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	cipherText, err := s.encrypt(doc.Salt, []byte(value))
+	if err != nil {
+		return err
+	}
+
+	if doc.Secrets == nil {
+		doc.Secrets = make(map[string][]byte)
+	}
+	doc.Secrets[key] = cipherText
+
+	return s.save(doc)
+}
+
+func (s *fileSecretStore) Delete(key string) error {
+	doc, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	delete(doc.Secrets, key)
+
+	return s.save(doc)
+}
+
+func (s *fileSecretStore) load() (*fileSecretStoreDocument, error) {
+	bytes, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		salt := make([]byte, fileSecretStoreSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		return &fileSecretStoreDocument{Salt: salt, Secrets: make(map[string][]byte)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc := new(fileSecretStoreDocument)
+	if err := json.Unmarshal(bytes, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func (s *fileSecretStore) save(doc *fileSecretStoreDocument) error {
+	bytes, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, bytes, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *fileSecretStore) deriveKey(salt []byte) ([]byte, error) {
+	return scrypt.Key(s.passphrase, salt, fileSecretStoreScryptN, fileSecretStoreScryptR, fileSecretStoreScryptP, fileSecretStoreKeySize)
+}
+
+func (s *fileSecretStore) encrypt(salt []byte, plain []byte) ([]byte, error) {
+	key, err := s.deriveKey(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plain, nil), nil
+}
+
+func (s *fileSecretStore) decrypt(salt []byte, cipherText []byte) ([]byte, error) {
+	key, err := s.deriveKey(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(cipherText) < nonceSize {
+		return nil, fmt.Errorf("secret store: malformed ciphertext")
+	}
+
+	nonce, cipherText := cipherText[:nonceSize], cipherText[nonceSize:]
+	return gcm.Open(nil, nonce, cipherText, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,46 @@
+// +build linux
+
+package core_config
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+)
+
+const secretCollection = "bluemix-cli"
+
+// libsecretStore stores secrets in the GNOME Keyring / any libsecret-compatible
+// collection via the `secret-tool` command line helper, avoiding a cgo
+// dependency on libsecret.
+type libsecretStore struct{}
+
+// NewSecretStore returns the preferred SecretStore for the current platform.
+func NewSecretStore() SecretStore {
+	return &libsecretStore{}
+}
+
+func (s *libsecretStore) Get(key string) (string, bool) {
+	out, err := exec.Command("secret-tool", "lookup", "collection", secretCollection, "key", key).Output()
+	if err != nil {
+		return "", false
+	}
+	return string(bytes.TrimSpace(out)), true
+}
+
+func (s *libsecretStore) Set(key string, value string) error {
+	cmd := exec.Command("secret-tool", "store", "--label="+key, "collection", secretCollection, "key", key)
+	cmd.Stdin = bytes.NewBufferString(value)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool: %v: %s", err, out)
+	}
+	return nil
+}
+
+func (s *libsecretStore) Delete(key string) error {
+	cmd := exec.Command("secret-tool", "clear", "collection", secretCollection, "key", key)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool: %v: %s", err, out)
+	}
+	return nil
+}
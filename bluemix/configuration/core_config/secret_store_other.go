@@ -0,0 +1,43 @@
+// +build !darwin,!linux,!windows
+
+package core_config
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/IBM-Bluemix/bluemix-cli-sdk/bluemix/configuration/config_helpers"
+)
+
+// NewSecretStore returns the preferred SecretStore for the current platform.
+// Platforms without a native credential store fall back to a passphrase
+// encrypted file next to the rest of the CLI's config.
+func NewSecretStore() SecretStore {
+	dir := config_helpers.ConfigDir()
+	return NewFileSecretStore(filepath.Join(dir, "secrets.json"), fallbackPassphrase(dir))
+}
+
+// fallbackPassphrase derives (and persists) a random per-install passphrase
+// used to protect the fallback secret file. It isn't meant to defend
+// against an attacker with read access to the config directory itself,
+// only against the secrets showing up in plain text in config.json.
+func fallbackPassphrase(dir string) string {
+	path := filepath.Join(dir, ".secret_key")
+
+	if bytes, err := ioutil.ReadFile(path); err == nil {
+		return string(bytes)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "bluemix-cli-fallback-key"
+	}
+
+	encoded := fmt.Sprintf("%x", key)
+	_ = os.MkdirAll(dir, 0700)
+	_ = ioutil.WriteFile(path, []byte(encoded), 0600)
+	return encoded
+}
@@ -0,0 +1,58 @@
+package core_config
+
+import "testing"
+
+// fakeSecretStore is an in-memory SecretStore for tests.
+type fakeSecretStore struct {
+	values map[string]string
+}
+
+func newFakeSecretStore() *fakeSecretStore {
+	return &fakeSecretStore{values: make(map[string]string)}
+}
+
+func (s *fakeSecretStore) Get(key string) (string, bool) {
+	v, ok := s.values[key]
+	return v, ok
+}
+
+func (s *fakeSecretStore) Set(key string, value string) error {
+	s.values[key] = value
+	return nil
+}
+
+func (s *fakeSecretStore) Delete(key string) error {
+	delete(s.values, key)
+	return nil
+}
+
+// TestNoopSecretStoreIsTruePassthrough guards against storeSecret treating
+// a nil/no-op secret store as a successful write to a real store: the
+// token must round-trip as the literal inline value, never as an
+// unresolvable "keyring:..." handle.
+func TestNoopSecretStoreIsTruePassthrough(t *testing.T) {
+	c := createBluemixConfigFromPersistorWithSecretStore(noopPersistor{}, noopSecretStore{}, func(error) {})
+
+	c.SetIAMToken("abc")
+
+	if got := c.IAMToken(); got != "abc" {
+		t.Errorf("IAMToken() = %q, want %q", got, "abc")
+	}
+}
+
+// TestRealSecretStoreStoresHandleNotToken asserts the opposite: with an
+// actual SecretStore wired in, the config file should hold an opaque
+// handle and the secret should live only in the store.
+func TestRealSecretStoreStoresHandleNotToken(t *testing.T) {
+	store := newFakeSecretStore()
+	c := createBluemixConfigFromPersistorWithSecretStore(noopPersistor{}, store, func(error) {})
+
+	c.SetIAMToken("abc")
+
+	if got := c.IAMToken(); got != "abc" {
+		t.Errorf("IAMToken() = %q, want %q", got, "abc")
+	}
+	if c.data.IAMToken == "abc" {
+		t.Error("IAMToken was stored inline instead of going through the secret store")
+	}
+}
@@ -0,0 +1,111 @@
+// +build windows
+
+package core_config
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const credentialPrefix = "bluemix-cli:"
+
+var (
+	advapi32        = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+)
+
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// wincredSecretStore stores secrets in the Windows Credential Manager via
+// the advapi32 Cred* APIs.
+type wincredSecretStore struct{}
+
+// NewSecretStore returns the preferred SecretStore for the current platform.
+func NewSecretStore() SecretStore {
+	return &wincredSecretStore{}
+}
+
+func (s *wincredSecretStore) Get(key string) (string, bool) {
+	target, err := syscall.UTF16PtrFromString(credentialPrefix + key)
+	if err != nil {
+		return "", false
+	}
+
+	var p uintptr
+	ret, _, _ := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&p)))
+	if ret == 0 {
+		return "", false
+	}
+	defer procCredFree.Call(p)
+
+	cred := (*credential)(unsafe.Pointer(p))
+	if cred.CredentialBlob == nil || cred.CredentialBlobSize == 0 {
+		return "", true
+	}
+
+	blob := (*[1 << 20]byte)(unsafe.Pointer(cred.CredentialBlob))[:cred.CredentialBlobSize:cred.CredentialBlobSize]
+	return string(blob), true
+}
+
+func (s *wincredSecretStore) Set(key string, value string) error {
+	target, err := syscall.UTF16PtrFromString(credentialPrefix + key)
+	if err != nil {
+		return err
+	}
+
+	blob := []byte(value)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+
+	ret, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if ret == 0 {
+		return fmt.Errorf("wincred: %v", err)
+	}
+	return nil
+}
+
+func (s *wincredSecretStore) Delete(key string) error {
+	target, err := syscall.UTF16PtrFromString(credentialPrefix + key)
+	if err != nil {
+		return err
+	}
+
+	ret, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0)
+	if ret == 0 {
+		// ERROR_NOT_FOUND: there was nothing to delete.
+		if err == syscall.Errno(1168) {
+			return nil
+		}
+		return fmt.Errorf("wincred: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package core_config
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// defaultIAMTokenRefreshSkew is how far ahead of the token's actual
+// expiration IAMToken() will proactively refresh it.
+const defaultIAMTokenRefreshSkew = 60 * time.Second
+
+// TokenRefresher exchanges a refresh token for a new access token (and,
+// when the IAM endpoint rotates it, a new refresh token). It is invoked
+// at most once per distinct refresh token at a time, regardless of how
+// many goroutines call IAMToken()/RefreshIAMToken() concurrently.
+type TokenRefresher func(refreshToken string) (accessToken string, newRefreshToken string, err error)
+
+// TokenSource is anything that can hand back the current access token,
+// refreshing it first if necessary. *bxConfigRepository implements it.
+type TokenSource interface {
+	IAMToken() string
+	IAMTokenValid() bool
+	RefreshIAMToken(ctx context.Context) (string, error)
+}
+
+// SetTokenRefresher installs the callback used to exchange a refresh
+// token for a new access token. Until one is set, IAMToken() returns
+// whatever is on disk without attempting to refresh it.
+func (c *bxConfigRepository) SetTokenRefresher(refresher TokenRefresher) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tokenRefresher = refresher
+}
+
+// SetTokenRefreshSkew overrides how far ahead of expiration IAMToken()
+// refreshes the token. The default is 60 seconds.
+func (c *bxConfigRepository) SetTokenRefreshSkew(skew time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tokenRefreshSkew = skew
+}
+
+// IAMTokenValid reports whether the token returned by IAMToken() is present
+// and not expired; when a TokenRefresher is set, that token may already
+// have been refreshed by the call. Tokens whose expiration can't be
+// determined (non-JWT, or no token at all) are treated as invalid.
+func (c *bxConfigRepository) IAMTokenValid() bool {
+	token := c.IAMToken()
+	if token == "" {
+		return false
+	}
+
+	exp, ok := jwtExpiry(token)
+	if !ok {
+		return false
+	}
+	return time.Now().Before(exp)
+}
+
+// RefreshIAMToken exchanges the current refresh token for a new access
+// token via the configured TokenRefresher, persists both tokens, and
+// returns the new access token. Concurrent callers refreshing the same
+// refresh token share a single in-flight request.
+func (c *bxConfigRepository) RefreshIAMToken(ctx context.Context) (string, error) {
+	c.lock.RLock()
+	refresher := c.tokenRefresher
+	c.lock.RUnlock()
+
+	if refresher == nil {
+		return "", fmt.Errorf("core_config: no TokenRefresher configured")
+	}
+
+	refreshToken := c.IAMRefreshToken()
+	if refreshToken == "" {
+		return "", fmt.Errorf("core_config: no IAM refresh token available")
+	}
+
+	v, err, _ := c.refreshGroup.Do(refreshToken, func() (interface{}, error) {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		access, newRefresh, err := refresher(refreshToken)
+		if err != nil {
+			return "", err
+		}
+
+		c.SetIAMToken(access)
+		if newRefresh != "" {
+			c.SetIAMRefreshToken(newRefresh)
+		}
+		return access, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return v.(string), nil
+}
+
+// refreshSkew returns the configured skew, falling back to the default
+// when none has been set.
+func (c *bxConfigRepository) refreshSkew() time.Duration {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.tokenRefreshSkew == 0 {
+		return defaultIAMTokenRefreshSkew
+	}
+	return c.tokenRefreshSkew
+}
+
+// jwtExpiry extracts the "exp" claim from a JWT's payload without
+// verifying its signature; it is only used to decide whether a token we
+// already trust is close to expiring.
+func jwtExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(claims.Exp, 0), true
+}
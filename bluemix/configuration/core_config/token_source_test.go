@@ -0,0 +1,210 @@
+package core_config
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IBM-Bluemix/bluemix-cli-sdk/bluemix/configuration"
+)
+
+// makeJWT builds a minimal (unsigned) JWT carrying only an "exp" claim, the
+// only part jwtExpiry looks at.
+func makeJWT(exp time.Time) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	payload, _ := json.Marshal(struct {
+		Exp int64 `json:"exp"`
+	}{Exp: exp.Unix()})
+	return header + "." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+// noopPersistor discards Save and never has anything to Load, so tests can
+// drive a bxConfigRepository purely in memory.
+type noopPersistor struct{}
+
+func (noopPersistor) Load(configuration.Data) error { return nil }
+func (noopPersistor) Save(configuration.Data) error { return nil }
+
+func newTestConfig() *bxConfigRepository {
+	return createBluemixConfigFromPersistorWithSecretStore(noopPersistor{}, noopSecretStore{}, func(error) {})
+}
+
+// TestRefreshIAMTokenSingleflight asserts that concurrent RefreshIAMToken
+// calls for the same refresh token collapse into a single call to the
+// configured TokenRefresher, as promised by RefreshIAMToken's doc comment.
+func TestRefreshIAMTokenSingleflight(t *testing.T) {
+	c := newTestConfig()
+	c.SetIAMRefreshToken("refresh-1")
+
+	var calls int32
+	unblock := make(chan struct{})
+	c.SetTokenRefresher(func(refreshToken string) (string, string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-unblock
+		return "access-1", "refresh-2", nil
+	})
+
+	const concurrency = 10
+	var wg sync.WaitGroup
+	results := make([]string, concurrency)
+	for i := 0; i < concurrency; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := c.RefreshIAMToken(context.Background())
+			if err != nil {
+				t.Errorf("RefreshIAMToken() error = %v", err)
+				return
+			}
+			results[i] = token
+		}()
+	}
+
+	// Give every goroutine time to join the in-flight refresh before it
+	// completes; singleflight only merges calls that overlap.
+	time.Sleep(100 * time.Millisecond)
+	close(unblock)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("TokenRefresher called %d times, want 1", got)
+	}
+	for i, token := range results {
+		if token != "access-1" {
+			t.Errorf("result[%d] = %q, want %q", i, token, "access-1")
+		}
+	}
+	if got := c.IAMRefreshToken(); got != "refresh-2" {
+		t.Errorf("IAMRefreshToken() = %q, want %q", got, "refresh-2")
+	}
+}
+
+// TestRefreshIAMTokenDistinctRefreshTokensDoNotShare asserts that refreshes
+// for different refresh tokens each reach the TokenRefresher with their own
+// refresh token instead of reusing an earlier refresh's result.
+func TestRefreshIAMTokenDistinctRefreshTokensDoNotShare(t *testing.T) {
+	c := newTestConfig()
+	c.SetIAMRefreshToken("refresh-a")
+	c.SetTokenRefresher(func(refreshToken string) (string, string, error) {
+		return "access-for-" + refreshToken, "", nil
+	})
+
+	token, err := c.RefreshIAMToken(context.Background())
+	if err != nil {
+		t.Fatalf("RefreshIAMToken() error = %v", err)
+	}
+	if want := "access-for-refresh-a"; token != want {
+		t.Errorf("RefreshIAMToken() = %q, want %q", token, want)
+	}
+
+	c.SetIAMRefreshToken("refresh-b")
+	token, err = c.RefreshIAMToken(context.Background())
+	if err != nil {
+		t.Fatalf("RefreshIAMToken() error = %v", err)
+	}
+	if want := "access-for-refresh-b"; token != want {
+		t.Errorf("RefreshIAMToken() = %q, want %q", token, want)
+	}
+}
+
+// TestIAMTokenValid covers IAMTokenValid's documented contract: a token
+// whose expiration can't be determined is treated as invalid, same as no
+// token at all or a token past its expiry.
+func TestIAMTokenValid(t *testing.T) {
+	cases := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{name: "no token", token: "", want: false},
+		{name: "non-JWT token", token: "opaque-token", want: false},
+		{name: "unexpired JWT", token: makeJWT(time.Now().Add(time.Hour)), want: true},
+		{name: "expired JWT", token: makeJWT(time.Now().Add(-time.Hour)), want: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestConfig()
+			if tc.token != "" {
+				c.SetIAMToken(tc.token)
+			}
+
+			if got := c.IAMTokenValid(); got != tc.want {
+				t.Errorf("IAMTokenValid() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestIAMTokenRefreshesWhenNearExpiry asserts IAMToken() proactively
+// refreshes a JWT within its refresh skew of expiring, and returns the
+// refreshed token.
+func TestIAMTokenRefreshesWhenNearExpiry(t *testing.T) {
+	c := newTestConfig()
+	c.SetIAMToken(makeJWT(time.Now().Add(10 * time.Second)))
+	c.SetIAMRefreshToken("refresh-1")
+	c.SetTokenRefreshSkew(time.Minute)
+
+	var calls int32
+	refreshed := makeJWT(time.Now().Add(time.Hour))
+	c.SetTokenRefresher(func(refreshToken string) (string, string, error) {
+		atomic.AddInt32(&calls, 1)
+		return refreshed, "", nil
+	})
+
+	if got := c.IAMToken(); got != refreshed {
+		t.Errorf("IAMToken() = %q, want refreshed token %q", got, refreshed)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("TokenRefresher called %d times, want 1", got)
+	}
+}
+
+// TestIAMTokenDoesNotRefreshWellBeforeExpiry asserts IAMToken() leaves a
+// token alone when it isn't close to expiring yet.
+func TestIAMTokenDoesNotRefreshWellBeforeExpiry(t *testing.T) {
+	c := newTestConfig()
+	token := makeJWT(time.Now().Add(time.Hour))
+	c.SetIAMToken(token)
+	c.SetIAMRefreshToken("refresh-1")
+
+	var calls int32
+	c.SetTokenRefresher(func(refreshToken string) (string, string, error) {
+		atomic.AddInt32(&calls, 1)
+		return "should-not-be-used", "", nil
+	})
+
+	if got := c.IAMToken(); got != token {
+		t.Errorf("IAMToken() = %q, want unchanged %q", got, token)
+	}
+	if got := atomic.LoadInt32(&calls); got != 0 {
+		t.Errorf("TokenRefresher called %d times, want 0", got)
+	}
+}
+
+// TestIAMTokenNonJWTTriggersRefresh asserts IAMToken() attempts a refresh
+// for a token whose expiry can't be determined, consistent with
+// IAMTokenValid treating the same token as invalid.
+func TestIAMTokenNonJWTTriggersRefresh(t *testing.T) {
+	c := newTestConfig()
+	c.SetIAMToken("opaque-token")
+	c.SetIAMRefreshToken("refresh-1")
+
+	var calls int32
+	c.SetTokenRefresher(func(refreshToken string) (string, string, error) {
+		atomic.AddInt32(&calls, 1)
+		return "access-1", "", nil
+	})
+
+	if got := c.IAMToken(); got != "access-1" {
+		t.Errorf("IAMToken() = %q, want %q", got, "access-1")
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("TokenRefresher called %d times, want 1", got)
+	}
+}
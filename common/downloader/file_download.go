@@ -1,14 +1,20 @@
 package downloader
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 type ProxyReader interface {
@@ -16,6 +22,21 @@ type ProxyReader interface {
 	Finish()
 }
 
+// ProgressReporter aggregates progress across the parallel workers used by
+// a multi-part download into a single running total, e.g. to drive one
+// progress bar for the whole file.
+type ProgressReporter interface {
+	Start(total int64)
+	Add(n int64)
+	Finish()
+}
+
+const (
+	defaultMaxRetries  = 3
+	defaultBackoffBase = 500 * time.Millisecond
+	partSuffix         = ".part"
+)
+
 type FileDownloader struct {
 	SaveDir string
 
@@ -23,6 +44,36 @@ type FileDownloader struct {
 	Client        *http.Client
 
 	ProxyReader ProxyReader
+
+	// ExpectedSHA256, if set, is the lowercase hex SHA-256 digest the
+	// downloaded file must match. A mismatch is returned as an error and
+	// the partial file is left in place (as "<dest>.part") rather than
+	// renamed over any previous good copy.
+	ExpectedSHA256 string
+
+	// ExpectedSize, if set, is the size in bytes the downloaded file must
+	// match once complete.
+	ExpectedSize int64
+
+	// MaxRetries is how many additional attempts are made after a
+	// transient network error or 5xx response before giving up. Defaults
+	// to 3.
+	MaxRetries int
+
+	// Backoff returns how long to wait before retry attempt n (1-based).
+	// Defaults to exponential backoff starting at 500ms.
+	Backoff func(attempt int) time.Duration
+
+	// Concurrency, when greater than 1, splits the download into that many
+	// byte ranges fetched in parallel, provided the server advertises
+	// "Accept-Ranges: bytes" and a known Content-Length. Downloads fall
+	// back to the single-stream path otherwise.
+	Concurrency int
+
+	// Progress, when set, is notified of parallel download progress
+	// across all workers combined. Ignored on the single-stream path,
+	// which reports through ProxyReader instead.
+	Progress ProgressReporter
 }
 
 func New(saveDir string) *FileDownloader {
@@ -34,13 +85,106 @@ func New(saveDir string) *FileDownloader {
 }
 
 func (d *FileDownloader) Download(url string) (dest string, size int64, err error) {
-	return d.DownloadTo(url, "")
+	return d.DownloadWithContext(context.Background(), url, "")
 }
 
 func (d *FileDownloader) DownloadTo(url string, outputName string) (dest string, size int64, err error) {
-	req, err := d.createRequest(url)
+	return d.DownloadWithContext(context.Background(), url, outputName)
+}
+
+// DownloadWithContext downloads url, resuming a previous partial download
+// (written to "<dest>.part") where possible and retrying transient
+// failures with backoff. ctx cancellation aborts the current attempt and
+// any pending retry wait. The partial file is kept on disk whenever the
+// download doesn't finish successfully, so a later call can resume it.
+func (d *FileDownloader) DownloadWithContext(ctx context.Context, rawurl string, outputName string) (dest string, size int64, err error) {
+	if d.Concurrency > 1 {
+		dest, size, err, ok := d.downloadParallel(ctx, rawurl, outputName)
+		if ok {
+			return dest, size, err
+		}
+		// server doesn't support ranged requests; fall back below.
+	}
+
+	maxRetries := d.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	}
+	backoff := d.Backoff
+	if backoff == nil {
+		backoff = defaultBackoff
+	}
+
+	digest := sha256.New()
+
+	var partPath string
+	if outputName != "" {
+		// dest/partPath are otherwise only known once the first response
+		// comes back (its headers may be all we have to name the file),
+		// but when the caller already tells us the name, resolve them up
+		// front so the very first request of this call - not just
+		// in-call retries - resumes a .part file left by an earlier,
+		// separate DownloadWithContext call instead of truncating it.
+		dest = filepath.Join(d.SaveDir, outputName)
+		partPath = dest + partSuffix
+
+		// Bytes already in the .part file will not be downloaded again,
+		// so feed them to digest before resuming.
+		if f, err := os.Open(partPath); err == nil {
+			_, err = io.Copy(digest, f)
+			f.Close()
+			if err != nil {
+				return dest, 0, err
+			}
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		dest, partPath, size, err = d.attemptDownload(ctx, rawurl, outputName, dest, partPath, digest)
+		if err == nil {
+			break
+		}
+		if !isRetryable(err) || attempt >= maxRetries {
+			return dest, size, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return dest, size, ctx.Err()
+		case <-time.After(backoff(attempt + 1)):
+		}
+	}
+
+	if err := d.verify(size, hex.EncodeToString(digest.Sum(nil))); err != nil {
+		return dest, size, err
+	}
+
+	if err := os.Rename(partPath, dest); err != nil {
+		return dest, size, err
+	}
+
+	return dest, size, nil
+}
+
+// attemptDownload performs a single request for rawurl, resuming from any
+// bytes already written to partPath via a Range request. When partPath is
+// still empty (no outputName, first attempt) the request starts from
+// scratch. digest is fed every byte of the file, in
+// order, across attempts, so the final digest is always over the whole
+// file regardless of how many attempts it took.
+func (d *FileDownloader) attemptDownload(ctx context.Context, rawurl string, outputName string, dest string, partPath string, digest hash.Hash) (string, string, int64, error) {
+	var resumeFrom int64
+	if partPath != "" {
+		if fi, err := os.Stat(partPath); err == nil {
+			resumeFrom = fi.Size()
+		}
+	}
+
+	req, err := d.createRequest(ctx, rawurl)
 	if err != nil {
-		return "", 0, fmt.Errorf("download request error: %v", err)
+		return dest, partPath, 0, fmt.Errorf("download request error: %v", err)
+	}
+	if resumeFrom > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", resumeFrom))
 	}
 
 	client := d.Client
@@ -50,51 +194,102 @@ func (d *FileDownloader) DownloadTo(url string, outputName string) (dest string,
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", 0, err
+		return dest, partPath, resumeFrom, retryableError(err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return "", 0, fmt.Errorf("Unexpected response code %d", resp.StatusCode)
+	switch resp.StatusCode {
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The server considers the range starting at resumeFrom beyond
+		// the end of the file, i.e. we already have everything.
+		return dest, partPath, resumeFrom, nil
+	case http.StatusOK:
+		// Either we didn't ask for a range, or the server doesn't
+		// support them; start over from scratch.
+		resumeFrom = 0
+		digest.Reset()
+	case http.StatusPartialContent:
+		// continuing a resumed download
+	default:
+		if resp.StatusCode >= 500 {
+			return dest, partPath, resumeFrom, retryableError(fmt.Errorf("server error %d", resp.StatusCode))
+		}
+		return dest, partPath, resumeFrom, fmt.Errorf("Unexpected response code %d", resp.StatusCode)
 	}
 
-	if outputName == "" {
-		outputName = d.determinOutputName(resp)
+	if dest == "" {
+		if outputName == "" {
+			outputName = d.determinOutputName(resp)
+		}
+		dest = filepath.Join(d.SaveDir, outputName)
+		partPath = dest + partSuffix
 	}
-	dest = filepath.Join(d.SaveDir, outputName)
 
-	f, err := os.OpenFile(dest, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	flags := os.O_RDWR | os.O_CREATE
+	if resumeFrom == 0 {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(partPath, flags, 0600)
 	if err != nil {
-		return dest, 0, err
+		return dest, partPath, resumeFrom, err
 	}
 	defer f.Close()
 
-	var r io.Reader = resp.Body
+	if resumeFrom == 0 {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return dest, partPath, resumeFrom, err
+		}
+	} else if _, err := f.Seek(resumeFrom, io.SeekStart); err != nil {
+		return dest, partPath, resumeFrom, err
+	}
+
+	totalSize := resp.ContentLength
+	if resp.StatusCode == http.StatusPartialContent {
+		totalSize = resumeFrom + resp.ContentLength
+	}
+
+	var r io.Reader = io.TeeReader(resp.Body, digest)
 	if d.ProxyReader != nil {
 		defer d.ProxyReader.Finish()
-		r = d.ProxyReader.Proxy(resp.ContentLength, r)
+		r = d.ProxyReader.Proxy(totalSize, r)
 	}
 
-	size, err = io.Copy(f, r)
+	written, err := io.Copy(f, r)
+	size := resumeFrom + written
 	if err != nil {
-		return dest, size, err
+		return dest, partPath, size, retryableError(err)
 	}
 
-	return dest, size, nil
+	return dest, partPath, size, nil
+}
+
+// verify checks a completed download against ExpectedSize/ExpectedSHA256.
+// sum is the hex-encoded SHA-256 digest of the downloaded file.
+func (d *FileDownloader) verify(size int64, sum string) error {
+	if d.ExpectedSize != 0 && size != d.ExpectedSize {
+		return fmt.Errorf("download size mismatch: expected %d bytes, got %d", d.ExpectedSize, size)
+	}
+
+	if d.ExpectedSHA256 != "" && !strings.EqualFold(sum, d.ExpectedSHA256) {
+		return fmt.Errorf("download checksum mismatch: expected %s, got %s", d.ExpectedSHA256, sum)
+	}
+
+	return nil
 }
 
 func (d *FileDownloader) RemoveDir() error {
 	return os.RemoveAll(d.SaveDir)
 }
 
-func (d *FileDownloader) createRequest(url string) (*http.Request, error) {
+func (d *FileDownloader) createRequest(ctx context.Context, url string) (*http.Request, error) {
 	r, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
+	r = r.WithContext(ctx)
 
 	if d.DefaultHeader != nil {
-		r.Header = d.DefaultHeader
+		r.Header = d.DefaultHeader.Clone()
 	}
 
 	if r.Header.Get("User-Agent") == "" {
@@ -132,19 +327,108 @@ func getFileNameFromUrl(url *url.URL) string {
 	return fields[len(fields)-1]
 }
 
+// getFileNameFromHeader extracts a file name from a Content-Disposition
+// header value per RFC 6266, preferring the RFC 5987 extended
+// "filename*=charset'lang'value" form over the plain "filename=" one when
+// both are present. The result is run through filepath.Base so a
+// malicious "filename*=../../etc/passwd" can't escape SaveDir.
 func getFileNameFromHeader(header string) string {
 	if header == "" {
 		return ""
 	}
 
+	if name, ok := extendedFileName(header); ok {
+		return sanitizeFileName(name)
+	}
+
+	_, params, err := mime.ParseMediaType(header)
+	if err != nil {
+		return ""
+	}
+
+	if name := params["filename"]; name != "" {
+		return sanitizeFileName(name)
+	}
+
+	return ""
+}
+
+// extendedFileName looks for a "filename*=" parameter and decodes its
+// RFC 5987 value: an IANA charset, a (usually empty) language tag, and a
+// percent-encoded value, separated by single quotes.
+func extendedFileName(header string) (string, bool) {
 	for _, field := range strings.Split(header, ";") {
 		field = strings.TrimSpace(field)
 
-		if strings.HasPrefix(field, "filename=") {
-			name := strings.TrimLeft(field, "filename=")
-			return strings.Trim(name, `"`)
+		value := strings.TrimPrefix(field, "filename*=")
+		if value == field {
+			continue
+		}
+		value = strings.Trim(value, `"`)
+
+		parts := strings.SplitN(value, "'", 3)
+		if len(parts) != 3 {
+			return "", false
+		}
+		charset, encoded := parts[0], parts[2]
+
+		decoded, err := url.PathUnescape(encoded)
+		if err != nil {
+			return "", false
 		}
+
+		if strings.EqualFold(charset, "iso-8859-1") {
+			decoded = latin1ToUTF8(decoded)
+		}
+
+		return decoded, true
 	}
 
-	return ""
+	return "", false
+}
+
+// latin1ToUTF8 reinterprets s, a string of ISO-8859-1 bytes, as UTF-8 by
+// treating each byte as the Unicode code point it maps to 1:1 in Latin-1.
+func latin1ToUTF8(s string) string {
+	runes := make([]rune, len(s))
+	for i, b := range []byte(s) {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// sanitizeFileName strips any directory components from name so a
+// server-supplied file name can't be used for path traversal.
+func sanitizeFileName(name string) string {
+	name = filepath.Base(name)
+	if name == "." || name == string(filepath.Separator) {
+		return ""
+	}
+	return name
+}
+
+// retryableErr wraps an error encountered while downloading to mark it as
+// worth retrying (a transient network error or 5xx response), as opposed
+// to e.g. a 4xx response or a local filesystem error.
+type retryableErr struct {
+	err error
+}
+
+func (e *retryableErr) Error() string { return e.err.Error() }
+func (e *retryableErr) Unwrap() error { return e.err }
+
+func retryableError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableErr{err: err}
+}
+
+func isRetryable(err error) bool {
+	_, ok := err.(*retryableErr)
+	return ok
+}
+
+func defaultBackoff(attempt int) time.Duration {
+	return defaultBackoffBase * time.Duration(1<<uint(attempt-1))
 }
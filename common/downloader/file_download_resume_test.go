@@ -0,0 +1,74 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestDownloadWithContextResumesAcrossCalls asserts the motivating scenario
+// for resumable downloads: a .part file left behind by an earlier, separate
+// DownloadWithContext call (e.g. one that exhausted its retries, or a crash
+// between process invocations) is resumed via a Range request on the very
+// first request of the next call, not truncated.
+func TestDownloadWithContextResumesAcrossCalls(t *testing.T) {
+	const content = "0123456789"
+	var gotRangeHeader string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRangeHeader = r.Header.Get("Range")
+
+		if gotRangeHeader == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(content))
+			return
+		}
+
+		from := strings.TrimSuffix(strings.TrimPrefix(gotRangeHeader, "bytes="), "-")
+		start, err := strconv.Atoi(from)
+		if err != nil {
+			t.Fatalf("unexpected Range header %q", gotRangeHeader)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)-start))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(content[start:]))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	const outputName = "plugin.zip"
+
+	// Simulate a prior, separate call that wrote the first half of the
+	// file and then failed outright (crash, exhausted retries, ...).
+	partPath := filepath.Join(dir, outputName+partSuffix)
+	if err := os.WriteFile(partPath, []byte(content[:5]), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	d := New(dir)
+	dest, size, err := d.DownloadWithContext(context.Background(), server.URL, outputName)
+	if err != nil {
+		t.Fatalf("DownloadWithContext() error = %v", err)
+	}
+
+	if gotRangeHeader != "bytes=5-" {
+		t.Errorf("first request Range header = %q, want %q", gotRangeHeader, "bytes=5-")
+	}
+	if size != int64(len(content)) {
+		t.Errorf("size = %d, want %d", size, len(content))
+	}
+
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Errorf("downloaded content = %q, want %q", got, content)
+	}
+}
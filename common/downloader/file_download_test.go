@@ -0,0 +1,71 @@
+package downloader
+
+import "testing"
+
+func TestGetFileNameFromHeader(t *testing.T) {
+	cases := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{
+			name:   "empty header",
+			header: "",
+			want:   "",
+		},
+		{
+			name:   "plain filename",
+			header: `attachment; filename="plugin.zip"`,
+			want:   "plugin.zip",
+		},
+		{
+			name:   "unquoted plain filename",
+			header: "attachment; filename=plugin.zip",
+			want:   "plugin.zip",
+		},
+		{
+			name:   "RFC 5987 extended value, no language tag",
+			header: `attachment; filename*=UTF-8''plugin.zip`,
+			want:   "plugin.zip",
+		},
+		{
+			name:   "RFC 5987 example: euro sign",
+			header: `attachment; filename*=UTF-8''%e2%82%ac%20rates.zip`,
+			want:   "€ rates.zip",
+		},
+		{
+			name:   "RFC 5987 example: ISO-8859-1 with language tag",
+			header: `attachment; filename*=iso-8859-1'en'%A3%20rates.zip`,
+			want:   "£ rates.zip",
+		},
+		{
+			name:   "extended value preferred over plain filename",
+			header: `attachment; filename="fallback.zip"; filename*=UTF-8''real.zip`,
+			want:   "real.zip",
+		},
+		{
+			name:   "extended value path traversal is sanitized",
+			header: `attachment; filename*=UTF-8''..%2F..%2Fetc%2Fpasswd`,
+			want:   "passwd",
+		},
+		{
+			name:   "plain filename path traversal is sanitized",
+			header: `attachment; filename="../../etc/passwd"`,
+			want:   "passwd",
+		},
+		{
+			name:   "no filename parameter",
+			header: "attachment",
+			want:   "",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := getFileNameFromHeader(c.header)
+			if got != c.want {
+				t.Errorf("getFileNameFromHeader(%q) = %q, want %q", c.header, got, c.want)
+			}
+		})
+	}
+}
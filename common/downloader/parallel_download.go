@@ -0,0 +1,269 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// chunkRange is a closed byte range [start, end] (both ends inclusive, matching
+// the HTTP Range header convention) assigned to one worker.
+type chunkRange struct {
+	start, end int64
+}
+
+// downloadParallel attempts a multi-part download of rawurl using
+// d.Concurrency workers. The final bool reports whether the parallel path
+// was actually usable: false means the server doesn't support ranged
+// requests and the caller should fall back to the single-stream path;
+// in that case dest/size/err are always zero values.
+func (d *FileDownloader) downloadParallel(ctx context.Context, rawurl string, outputName string) (dest string, size int64, err error, ok bool) {
+	req, err := d.createRequest(ctx, rawurl)
+	if err != nil {
+		return "", 0, fmt.Errorf("download request error: %v", err), true
+	}
+	req.Header.Set("Range", "bytes=0-0")
+
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	probe, err := client.Do(req)
+	if err != nil {
+		return "", 0, err, true
+	}
+	probe.Body.Close()
+
+	if probe.StatusCode != http.StatusPartialContent || probe.Header.Get("Accept-Ranges") != "bytes" {
+		return "", 0, nil, false
+	}
+
+	total, supported := parseContentRangeSize(probe.Header.Get("Content-Range"))
+	if !supported || total <= 0 {
+		return "", 0, nil, false
+	}
+
+	if outputName == "" {
+		outputName = d.determinOutputName(probe)
+	}
+	dest = filepath.Join(d.SaveDir, outputName)
+	partPath := dest + partSuffix
+
+	f, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return dest, 0, err, true
+	}
+	if err := f.Truncate(total); err != nil {
+		f.Close()
+		return dest, 0, err, true
+	}
+
+	concurrency := d.Concurrency
+	if int64(concurrency) > total {
+		concurrency = int(total)
+	}
+	chunks := splitIntoChunks(total, concurrency)
+
+	reporter := d.Progress
+	if reporter != nil {
+		reporter.Start(total)
+		defer reporter.Finish()
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for _, c := range chunks {
+		c := c
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.downloadChunk(ctx, rawurl, f, c, reporter); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := f.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if firstErr != nil {
+		return dest, 0, firstErr, true
+	}
+
+	sum, err := sha256File(partPath)
+	if err != nil {
+		return dest, total, err, true
+	}
+	if err := d.verify(total, sum); err != nil {
+		return dest, total, err, true
+	}
+
+	if err := os.Rename(partPath, dest); err != nil {
+		return dest, total, err, true
+	}
+
+	return dest, total, nil, true
+}
+
+// downloadChunk fetches c.start-c.end of rawurl and writes it into f at
+// offset c.start, retrying transient failures with d's configured backoff.
+func (d *FileDownloader) downloadChunk(ctx context.Context, rawurl string, f *os.File, c chunkRange, reporter ProgressReporter) error {
+	maxRetries := d.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	}
+	backoff := d.Backoff
+	if backoff == nil {
+		backoff = defaultBackoff
+	}
+
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := d.fetchChunk(ctx, client, rawurl, f, c, reporter)
+		if err == nil {
+			return nil
+		}
+		if !isRetryable(err) || attempt >= maxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff(attempt + 1)):
+		}
+	}
+}
+
+func (d *FileDownloader) fetchChunk(ctx context.Context, client *http.Client, rawurl string, f *os.File, c chunkRange, reporter ProgressReporter) error {
+	req, err := d.createRequest(ctx, rawurl)
+	if err != nil {
+		return fmt.Errorf("download request error: %v", err)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", c.start, c.end))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return retryableError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		if resp.StatusCode >= 500 {
+			return retryableError(fmt.Errorf("server error %d", resp.StatusCode))
+		}
+		return fmt.Errorf("Unexpected response code %d", resp.StatusCode)
+	}
+
+	w := &sectionWriter{f: f, offset: c.start}
+	var r io.Reader = resp.Body
+	if reporter != nil {
+		r = &countingReader{r: r, reporter: reporter}
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		return retryableError(err)
+	}
+
+	return nil
+}
+
+// sectionWriter writes sequentially to f starting at offset, advancing
+// offset by each write's length so multiple sectionWriters can safely
+// share one *os.File as long as their ranges don't overlap.
+type sectionWriter struct {
+	f      *os.File
+	offset int64
+}
+
+func (w *sectionWriter) Write(p []byte) (int, error) {
+	n, err := w.f.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
+
+// countingReader reports every byte read to a shared ProgressReporter so
+// concurrent chunk downloads can drive a single aggregate progress bar.
+type countingReader struct {
+	r        io.Reader
+	reporter ProgressReporter
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.reporter.Add(int64(n))
+	}
+	return n, err
+}
+
+func splitIntoChunks(total int64, n int) []chunkRange {
+	chunkSize := total / int64(n)
+	chunks := make([]chunkRange, 0, n)
+
+	var start int64
+	for i := 0; i < n; i++ {
+		end := start + chunkSize - 1
+		if i == n-1 {
+			end = total - 1
+		}
+		chunks = append(chunks, chunkRange{start: start, end: end})
+		start = end + 1
+	}
+
+	return chunks
+}
+
+// parseContentRangeSize extracts the total resource size from a
+// "Content-Range: bytes 0-0/12345" header value.
+func parseContentRangeSize(headerValue string) (int64, bool) {
+	idx := strings.LastIndex(headerValue, "/")
+	if idx == -1 || idx == len(headerValue)-1 {
+		return 0, false
+	}
+
+	total, err := strconv.ParseInt(headerValue[idx+1:], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return total, true
+}
+
+// sha256File returns the hex-encoded SHA-256 digest of the file at path.
+// Used by the parallel path, where writes happen out of order across
+// workers so the digest can't be streamed alongside them.
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,244 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSplitIntoChunks(t *testing.T) {
+	cases := []struct {
+		name  string
+		total int64
+		n     int
+		want  []chunkRange
+	}{
+		{
+			name:  "single chunk",
+			total: 10,
+			n:     1,
+			want:  []chunkRange{{start: 0, end: 9}},
+		},
+		{
+			name:  "evenly divisible",
+			total: 10,
+			n:     2,
+			want:  []chunkRange{{start: 0, end: 4}, {start: 5, end: 9}},
+		},
+		{
+			name:  "remainder folded into last chunk",
+			total: 10,
+			n:     3,
+			want:  []chunkRange{{start: 0, end: 2}, {start: 3, end: 5}, {start: 6, end: 9}},
+		},
+		{
+			name:  "one byte per chunk",
+			total: 3,
+			n:     3,
+			want:  []chunkRange{{start: 0, end: 0}, {start: 1, end: 1}, {start: 2, end: 2}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := splitIntoChunks(tc.total, tc.n)
+			if len(got) != len(tc.want) {
+				t.Fatalf("splitIntoChunks(%d, %d) = %v, want %v", tc.total, tc.n, got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("chunk[%d] = %+v, want %+v", i, got[i], tc.want[i])
+				}
+			}
+			// Every byte in [0, total) must be covered by exactly one chunk.
+			var next int64
+			for _, c := range got {
+				if c.start != next {
+					t.Fatalf("chunks have a gap or overlap at byte %d: %+v", next, got)
+				}
+				next = c.end + 1
+			}
+			if next != tc.total {
+				t.Fatalf("chunks cover up to byte %d, want %d", next, tc.total)
+			}
+		})
+	}
+}
+
+func TestParseContentRangeSize(t *testing.T) {
+	cases := []struct {
+		name   string
+		header string
+		want   int64
+		wantOK bool
+	}{
+		{name: "valid", header: "bytes 0-0/12345", want: 12345, wantOK: true},
+		{name: "empty", header: "", wantOK: false},
+		{name: "no slash", header: "bytes 0-0", wantOK: false},
+		{name: "nothing after slash", header: "bytes 0-0/", wantOK: false},
+		{name: "non-numeric total", header: "bytes 0-0/*", wantOK: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := parseContentRangeSize(tc.header)
+			if ok != tc.wantOK {
+				t.Fatalf("parseContentRangeSize(%q) ok = %v, want %v", tc.header, ok, tc.wantOK)
+			}
+			if ok && got != tc.want {
+				t.Errorf("parseContentRangeSize(%q) = %d, want %d", tc.header, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestSectionWriterConcurrentWrites asserts that sectionWriters covering
+// disjoint ranges of the same *os.File can be written from multiple
+// goroutines concurrently without corrupting each other's bytes.
+func TestSectionWriterConcurrentWrites(t *testing.T) {
+	const (
+		numWriters = 8
+		chunkSize  = 1024
+	)
+
+	path := filepath.Join(t.TempDir(), "out")
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(numWriters * chunkSize); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < numWriters; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := &sectionWriter{f: f, offset: int64(i * chunkSize)}
+			buf := make([]byte, chunkSize)
+			for j := range buf {
+				buf[j] = byte(i)
+			}
+			if _, err := w.Write(buf); err != nil {
+				t.Errorf("writer %d: Write() error = %v", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < numWriters; i++ {
+		region := got[i*chunkSize : (i+1)*chunkSize]
+		for j, b := range region {
+			if b != byte(i) {
+				t.Fatalf("byte %d of writer %d's region = %d, want %d", j, i, b, i)
+			}
+		}
+	}
+}
+
+// rangeServer serves content over ranged GETs, as downloadParallel expects.
+func rangeServer(content string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rangeHeader := r.Header.Get("Range")
+		var start, end int64
+		if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-%d", &start, &end); err != nil {
+			http.Error(w, "expected a bounded Range header", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(content[start : end+1]))
+	}))
+}
+
+func TestDownloadParallelSuccess(t *testing.T) {
+	content := "the quick brown fox jumps over the lazy dog, 0123456789"
+	server := rangeServer(content)
+	defer server.Close()
+
+	d := New(t.TempDir())
+	d.Concurrency = 4
+
+	dest, size, err, ok := d.downloadParallel(context.Background(), server.URL, "out.bin")
+	if !ok {
+		t.Fatalf("downloadParallel() ok = false, want true")
+	}
+	if err != nil {
+		t.Fatalf("downloadParallel() error = %v", err)
+	}
+	if size != int64(len(content)) {
+		t.Errorf("size = %d, want %d", size, len(content))
+	}
+
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Errorf("downloaded content = %q, want %q", got, content)
+	}
+}
+
+func TestDownloadParallelFallsBackWhenRangesUnsupported(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("whole file, no ranges"))
+	}))
+	defer server.Close()
+
+	d := New(t.TempDir())
+	d.Concurrency = 4
+
+	_, _, err, ok := d.downloadParallel(context.Background(), server.URL, "out.bin")
+	if ok {
+		t.Fatal("downloadParallel() ok = true, want false when server doesn't support ranges")
+	}
+	if err != nil {
+		t.Errorf("downloadParallel() error = %v, want nil on fallback", err)
+	}
+}
+
+func TestDownloadParallelClampsConcurrencyToFileSize(t *testing.T) {
+	content := "abc"
+	server := rangeServer(content)
+	defer server.Close()
+
+	d := New(t.TempDir())
+	d.Concurrency = 8 // more workers than bytes in the file
+
+	dest, size, err, ok := d.downloadParallel(context.Background(), server.URL, "out.bin")
+	if !ok {
+		t.Fatalf("downloadParallel() ok = false, want true")
+	}
+	if err != nil {
+		t.Fatalf("downloadParallel() error = %v", err)
+	}
+	if size != int64(len(content)) {
+		t.Errorf("size = %d, want %d", size, len(content))
+	}
+
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Errorf("downloaded content = %q, want %q", got, content)
+	}
+}
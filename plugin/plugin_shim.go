@@ -1,8 +1,13 @@
 package plugin
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/IBM-Bluemix/bluemix-cli-sdk/bluemix"
 	"github.com/IBM-Bluemix/bluemix-cli-sdk/bluemix/configuration/config_helpers"
@@ -10,13 +15,52 @@ import (
 	"github.com/IBM-Bluemix/bluemix-cli-sdk/i18n"
 )
 
-// Run plugin with os.Args
+// Exit codes returned by Start/Run/StartV2/RunV2, standardized so the
+// parent CLI can tell why a plugin exited without scraping its output.
+const (
+	ExitOK       = 0
+	ExitUsage    = 2
+	ExitAuth     = 4
+	ExitPanic    = 70
+	ExitCanceled = 130
+)
+
+// Start runs plugin with os.Args, exiting the process with a standardized
+// exit code once it finishes. Unlike StartV2, SIGINT keeps its default
+// behavior of terminating the process immediately: the legacy Plugin.Run
+// has no ctx to react to cancellation, so installing a signal handler here
+// would only defang Ctrl-C without giving the plugin any way to honor it.
 func Start(plugin Plugin) {
-	Run(plugin, os.Args[1:])
+	os.Exit(run(pluginV2Adapter{plugin}, os.Args[1:], false))
 }
 
-// Run plugin with args
+// Run runs plugin with args, exiting the process with a standardized exit
+// code once it finishes. See Start for why SIGINT isn't intercepted here.
 func Run(plugin Plugin, args []string) {
+	os.Exit(run(pluginV2Adapter{plugin}, args, false))
+}
+
+// StartV2 runs plugin with os.Args using the PluginV2 lifecycle: ctx is
+// cancelled on SIGINT/SIGTERM, and a panic inside plugin.Run is recovered
+// and reported as ExitPanic instead of corrupting the parent CLI's
+// terminal.
+func StartV2(plugin PluginV2) {
+	os.Exit(run(plugin, os.Args[1:], true))
+}
+
+// RunV2 is StartV2 with an explicit argument list.
+func RunV2(plugin PluginV2, args []string) {
+	os.Exit(run(plugin, args, true))
+}
+
+func run(plugin PluginV2, args []string, withSignalHandling bool) (exitCode int) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "panic: %v\n", r)
+			exitCode = ExitPanic
+		}
+	}()
+
 	if isMetadataRequest(args) {
 		metadata := fillMetadata(plugin.GetMetadata())
 		json, err := json.Marshal(metadata)
@@ -24,15 +68,35 @@ func Run(plugin Plugin, args []string) {
 			panic(err)
 		}
 		os.Stdout.Write(json)
-		return
+		return ExitOK
+	}
+
+	ctx := context.Background()
+	if withSignalHandling {
+		var cancel context.CancelFunc
+		ctx, cancel = signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer cancel()
 	}
 
-	context := GetPluginContext(plugin.GetMetadata().Name)
+	pluginContext := GetPluginContext(plugin.GetMetadata().Name)
 
 	// initialization
-	i18n.T = i18n.Tfunc(context.Locale())
+	i18n.T = i18n.Tfunc(pluginContext.Locale())
+
+	err := plugin.Run(ctx, pluginContext, args)
+	if ctx.Err() != nil {
+		return ExitCanceled
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		var authErr *AuthError
+		if errors.As(err, &authErr) {
+			return ExitAuth
+		}
+		return ExitUsage
+	}
 
-	plugin.Run(context, args)
+	return ExitOK
 }
 
 func fillMetadata(metadata PluginMetadata) PluginMetadata {
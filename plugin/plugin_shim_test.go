@@ -0,0 +1,76 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+)
+
+// panickingPlugin is a PluginV2 whose Run always panics, used to exercise
+// run()'s recover path.
+type panickingPlugin struct {
+	name string
+}
+
+func (p panickingPlugin) GetMetadata() PluginMetadata {
+	return PluginMetadata{Name: p.name}
+}
+
+func (p panickingPlugin) Run(ctx context.Context, pluginContext PluginContext, args []string) error {
+	panic("boom")
+}
+
+// TestRunRecoversPanic asserts that a panic inside plugin.Run is turned
+// into ExitPanic instead of crashing the process and corrupting the
+// parent CLI's terminal.
+func TestRunRecoversPanic(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	exitCode := run(panickingPlugin{name: "test-plugin"}, []string{"do-something"}, false)
+
+	if exitCode != ExitPanic {
+		t.Errorf("run() = %d, want ExitPanic (%d)", exitCode, ExitPanic)
+	}
+}
+
+// TestRunWithSignalHandlingRecoversPanic is the same check with the V2
+// signal-handling path enabled, to make sure wiring signal.NotifyContext in
+// doesn't interfere with panic recovery.
+func TestRunWithSignalHandlingRecoversPanic(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	exitCode := run(panickingPlugin{name: "test-plugin"}, []string{"do-something"}, true)
+
+	if exitCode != ExitPanic {
+		t.Errorf("run() = %d, want ExitPanic (%d)", exitCode, ExitPanic)
+	}
+}
+
+// panickingMetadataPlugin panics out of GetMetadata, which run() calls (via
+// GetPluginContext) before plugin.Run is ever invoked. It exercises the
+// actual regression being guarded against: the recover defer has to wrap
+// plugin context initialization too, not just the plugin.Run call.
+type panickingMetadataPlugin struct{}
+
+func (panickingMetadataPlugin) GetMetadata() PluginMetadata {
+	panic("boom during metadata/config init")
+}
+
+func (panickingMetadataPlugin) Run(ctx context.Context, pluginContext PluginContext, args []string) error {
+	panic("plugin.Run should never be reached")
+}
+
+// TestRunRecoversPanicBeforePluginRun asserts that a panic raised while
+// resolving the plugin's metadata/context - before plugin.Run is ever
+// called - is still recovered as ExitPanic. Before this was fixed, the
+// recover defer was registered after GetPluginContext/i18n.Tfunc ran, so a
+// panic there (e.g. the onError handler panicking on a bad on-disk config)
+// would crash the process uncaught.
+func TestRunRecoversPanicBeforePluginRun(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	exitCode := run(panickingMetadataPlugin{}, []string{"do-something"}, false)
+
+	if exitCode != ExitPanic {
+		t.Errorf("run() = %d, want ExitPanic (%d)", exitCode, ExitPanic)
+	}
+}
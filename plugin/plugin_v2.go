@@ -0,0 +1,35 @@
+package plugin
+
+import "context"
+
+// PluginV2 is the plugin entry point used going forward. Unlike Plugin,
+// Run receives a context.Context that StartV2/RunV2 cancels on
+// SIGINT/SIGTERM, so plugins doing long-running work (uploads, polling a
+// backend job) get a chance to clean up instead of being killed mid-write.
+type PluginV2 interface {
+	GetMetadata() PluginMetadata
+	Run(ctx context.Context, pluginContext PluginContext, args []string) error
+}
+
+// AuthError marks a failure as an authentication/authorization problem so
+// StartV2/RunV2 can exit with ExitAuth instead of the generic ExitUsage.
+// Legacy plugins run through Start/Run never return an error.
+type AuthError struct {
+	Err error
+}
+
+func (e *AuthError) Error() string { return e.Err.Error() }
+func (e *AuthError) Unwrap() error { return e.Err }
+
+// pluginV2Adapter runs a legacy Plugin through the PluginV2 lifecycle
+// (panic recovery, exit codes; Start/Run install no signal handling) without
+// requiring it to change. It ignores ctx and the error return the old Plugin.Run doesn't
+// have, since legacy plugins have no way to observe either.
+type pluginV2Adapter struct {
+	Plugin
+}
+
+func (a pluginV2Adapter) Run(ctx context.Context, pluginContext PluginContext, args []string) error {
+	a.Plugin.Run(pluginContext, args)
+	return nil
+}